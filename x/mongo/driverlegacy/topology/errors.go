@@ -0,0 +1,51 @@
+// Copyright (C) MongoDB, Inc. 2017-present.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at http://www.apache.org/licenses/LICENSE-2.0
+
+package topology
+
+import "fmt"
+
+// This file intentionally doesn't define a ServerSelectionError: that type
+// wraps a failed server-selection attempt, and this package doesn't contain
+// a server-selection loop (topology/server selection lives above this
+// reduced slice of the driver), so there would be nothing to construct it.
+// Add it alongside ConnectionError and WaitQueueTimeoutError if and when
+// server selection is vendored in here.
+
+// ConnectionError is an error returned while establishing or using a
+// connection to a server. It wraps the underlying cause (a network error,
+// an auth.Error, or a cancelled context) so callers can use errors.Is and
+// errors.As instead of matching on the error string. An expired context is
+// wrapped in a WaitQueueTimeoutError instead.
+type ConnectionError struct {
+	Wrapped error
+}
+
+func (e ConnectionError) Error() string {
+	return fmt.Sprintf("connection error: %s", e.Wrapped)
+}
+
+// Unwrap returns the underlying cause of the connection error.
+func (e ConnectionError) Unwrap() error {
+	return e.Wrapped
+}
+
+// WaitQueueTimeoutError is returned when a caller gives up waiting for a
+// connection to become available from a server's pool before its context
+// was done. It wraps the context error so errors.Is(err,
+// context.DeadlineExceeded) reliably identifies the timeout.
+type WaitQueueTimeoutError struct {
+	Wrapped error
+}
+
+func (e WaitQueueTimeoutError) Error() string {
+	return fmt.Sprintf("timed out while checking out a connection from the connection pool: %s", e.Wrapped)
+}
+
+// Unwrap returns the underlying context error.
+func (e WaitQueueTimeoutError) Unwrap() error {
+	return e.Wrapped
+}
@@ -0,0 +1,98 @@
+// Copyright (C) MongoDB, Inc. 2017-present.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at http://www.apache.org/licenses/LICENSE-2.0
+
+package topology
+
+import (
+	"context"
+	"time"
+
+	"go.mongodb.org/mongo-driver/x/mongo/driverlegacy/command"
+	connectionlegacy "go.mongodb.org/mongo-driver/x/network/connection"
+)
+
+// failPointMode mirrors the "mode" document accepted by the server's
+// configureFailPoint command (see mtest.FailPoint): either the fail point
+// fires for a fixed number of invocations (Times), after skipping a number
+// of invocations first (Skip), or it's simply always on.
+type failPointMode struct {
+	Times    int
+	Skip     int
+	AlwaysOn bool
+}
+
+// failPointData mirrors the "data" document accepted by configureFailPoint:
+// the error the fail point should produce when it fires.
+type failPointData struct {
+	ErrorCode       int32
+	ErrorLabels     []string
+	BlockConnection bool
+	BlockTimeMS     int32
+	CloseConnection bool
+}
+
+// failPoint is a client-side stand-in for the server-side configureFailPoint
+// command, letting tests drive topology.Server's error-handling paths
+// without a live mongod.
+type failPoint struct {
+	name string
+	mode failPointMode
+	data failPointData
+}
+
+// SetFailPoint arms p with a fail point named name. Subsequent calls to
+// nextCommandError consult it, skipping mode.Skip invocations and then
+// firing for mode.Times invocations (or indefinitely if mode.AlwaysOn).
+func (p *testpool) SetFailPoint(name string, mode failPointMode, data failPointData) {
+	p.failPoint = &failPoint{name: name, mode: mode, data: data}
+}
+
+// nextCommandError simulates the result of running a command against the
+// server: nil if no fail point is armed, it hasn't started firing yet or it
+// has used up mode.Times; a *connection.NetworkError if the fail point's data
+// sets CloseConnection; or a *command.Error built from the fail point's data
+// otherwise. Each firing
+// decrements mode.Times, so callers can exercise "fails N times, then
+// succeeds" scenarios.
+//
+// When the fail point's data sets BlockConnection, the call blocks for
+// BlockTimeMS before producing its result, mirroring the server blocking
+// the connection before responding; ctx being done during the block takes
+// precedence over the configured error.
+func (p *testpool) nextCommandError(ctx context.Context) error {
+	fp := p.failPoint
+	if fp == nil {
+		return nil
+	}
+
+	if fp.mode.Skip > 0 {
+		fp.mode.Skip--
+		return nil
+	}
+
+	if !fp.mode.AlwaysOn {
+		if fp.mode.Times <= 0 {
+			return nil
+		}
+		fp.mode.Times--
+	}
+
+	if fp.data.BlockConnection && fp.data.BlockTimeMS > 0 {
+		select {
+		case <-time.After(time.Duration(fp.data.BlockTimeMS) * time.Millisecond):
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+
+	if fp.data.CloseConnection {
+		return &connectionlegacy.NetworkError{}
+	}
+	return &command.Error{
+		Code:   fp.data.ErrorCode,
+		Labels: fp.data.ErrorLabels,
+	}
+}
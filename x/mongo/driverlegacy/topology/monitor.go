@@ -0,0 +1,113 @@
+// Copyright (C) MongoDB, Inc. 2017-present.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at http://www.apache.org/licenses/LICENSE-2.0
+
+package topology
+
+import (
+	"context"
+	"time"
+
+	"go.mongodb.org/mongo-driver/x/network/description"
+)
+
+// minStreamingWireVersion is the lowest server wire version that supports
+// the "awaitable isMaster" streaming protocol. Servers below this version
+// only ever get polled on heartbeatInterval.
+const minStreamingWireVersion = 9
+
+// rttAlpha is the smoothing factor for the exponentially-weighted moving
+// average of a server's round-trip time. Lower values weight history more
+// heavily than the most recent sample.
+const rttAlpha = 0.2
+
+// isMasterer sends a single isMaster check against a server's dedicated
+// monitoring connection and reports how long it took. When streaming is
+// true, the check is sent as an "awaitable isMaster": maxAwaitTimeMS and
+// topologyVersion are included so the server blocks until its topology
+// version changes or the timeout elapses.
+type isMasterer interface {
+	isMaster(ctx context.Context, streaming bool, topologyVersion *description.TopologyVersion, maxAwaitTimeMS int64) (description.Server, time.Duration, error)
+}
+
+// serverMonitor periodically checks a server's isMaster, either by polling
+// on a fixed interval or, once streaming has kicked in, via the awaitable
+// isMaster protocol described in the server-monitoring spec. RTT is not
+// derived from these checks: once streaming engages, the isMaster call
+// itself can legitimately block on the server for up to maxAwaitTimeMS, so
+// serverMonitor delegates RTT tracking to a separate rtt monitor sampled on
+// its own, much shorter, cadence.
+type serverMonitor struct {
+	server *Server
+	conn   isMasterer
+	cfg    *serverConfig
+	rtt    *rttMonitor
+
+	// streaming is true once the monitor has successfully performed the
+	// first isMaster on the monitoring connection and has moved on to
+	// awaitable checks. It resets to false (falling back to polling) on
+	// a network error or if the server turns out not to support it.
+	streaming bool
+
+	topologyVersion *description.TopologyVersion
+}
+
+func newServerMonitor(server *Server, conn isMasterer, cfg *serverConfig, rtt *rttMonitor) *serverMonitor {
+	return &serverMonitor{
+		server: server,
+		conn:   conn,
+		cfg:    cfg,
+		rtt:    rtt,
+	}
+}
+
+// check runs a single isMaster check, updating the monitor's streaming
+// state and topology version, and returns the resulting server description
+// with AverageRTT filled in from the separate rtt monitor.
+func (m *serverMonitor) check(ctx context.Context) description.Server {
+	streaming := m.streaming && m.cfg.streamingEnabled
+	maxAwaitTimeMS := int64(0)
+	if streaming {
+		maxAwaitTimeMS = m.cfg.heartbeatInterval.Milliseconds()
+	}
+
+	desc, _, err := m.conn.isMaster(ctx, streaming, m.topologyVersion, maxAwaitTimeMS)
+	if err != nil {
+		// The streaming socket is dead; fall back to polling and let the
+		// next successful check re-establish it and re-evaluate streaming.
+		m.streaming = false
+		m.topologyVersion = nil
+		desc.Kind = description.Unknown
+		desc.LastError = err
+		desc.AverageRTT = m.rtt.getRTT()
+		return desc
+	}
+
+	if streaming && m.topologyVersion != nil && desc.TopologyVersion != nil &&
+		!topologyVersionChanged(m.topologyVersion, desc.TopologyVersion) {
+		// The server had nothing new to report.
+		desc.AverageRTT = m.rtt.getRTT()
+		return desc
+	}
+
+	m.topologyVersion = desc.TopologyVersion
+	desc.AverageRTT = m.rtt.getRTT()
+
+	// Engage streaming mode once we've completed a successful isMaster and
+	// the server has told us it supports it; otherwise keep polling.
+	m.streaming = m.cfg.streamingEnabled && desc.WireVersion != nil && desc.WireVersion.Max >= minStreamingWireVersion
+
+	return desc
+}
+
+// topologyVersionChanged reports whether latest differs from old. A server
+// increments Counter on every topology change, and changes ProcessId only
+// when the server process itself restarts.
+func topologyVersionChanged(old, latest *description.TopologyVersion) bool {
+	if old == nil || latest == nil {
+		return true
+	}
+	return old.ProcessID != latest.ProcessID || old.Counter != latest.Counter
+}
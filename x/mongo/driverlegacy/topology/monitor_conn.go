@@ -0,0 +1,58 @@
+// Copyright (C) MongoDB, Inc. 2017-present.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at http://www.apache.org/licenses/LICENSE-2.0
+
+package topology
+
+import (
+	"context"
+	"time"
+
+	"go.mongodb.org/mongo-driver/x/mongo/driverlegacy/command"
+	"go.mongodb.org/mongo-driver/x/network/address"
+	"go.mongodb.org/mongo-driver/x/network/connection"
+	"go.mongodb.org/mongo-driver/x/network/description"
+)
+
+// connIsMasterer is the production isMasterer: it dials and keeps its own
+// monitoring connection to addr, separate from the Server's regular
+// connection pool, and issues an isMaster over it on every check.
+type connIsMasterer struct {
+	addr address.Address
+	conn connection.Connection
+}
+
+func newConnIsMasterer(addr address.Address) *connIsMasterer {
+	return &connIsMasterer{addr: addr}
+}
+
+func (c *connIsMasterer) isMaster(ctx context.Context, streaming bool, topologyVersion *description.TopologyVersion, maxAwaitTimeMS int64) (description.Server, time.Duration, error) {
+	if c.conn == nil {
+		conn, err := connection.New(ctx, c.addr)
+		if err != nil {
+			return description.Server{Addr: c.addr}, 0, err
+		}
+		c.conn = conn
+	}
+
+	im := &command.IsMaster{}
+	if streaming {
+		im.TopologyVersion = topologyVersion
+		im.MaxAwaitTimeMS = maxAwaitTimeMS
+	}
+
+	start := time.Now()
+	res, err := im.RoundTrip(ctx, c.conn)
+	rtt := time.Since(start)
+	if err != nil {
+		// The monitoring connection can't be trusted once it's returned an
+		// error; drop it so the next check dials a fresh one.
+		_ = c.conn.Close()
+		c.conn = nil
+		return description.Server{Addr: c.addr}, rtt, err
+	}
+
+	return description.NewServer(c.addr, res), rtt, nil
+}
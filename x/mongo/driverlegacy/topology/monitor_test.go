@@ -0,0 +1,175 @@
+// Copyright (C) MongoDB, Inc. 2017-present.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at http://www.apache.org/licenses/LICENSE-2.0
+
+package topology
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/x/network/address"
+	"go.mongodb.org/mongo-driver/x/network/description"
+)
+
+// fakeIsMasterer plays back a scripted sequence of isMaster results so
+// tests can drive the streaming monitor without a live mongod.
+type fakeIsMasterer struct {
+	results []fakeIsMasterResult
+	calls   []bool // records the streaming flag each call was made with
+}
+
+type fakeIsMasterResult struct {
+	desc description.Server
+	rtt  time.Duration
+	err  error
+}
+
+func (f *fakeIsMasterer) isMaster(ctx context.Context, streaming bool, topologyVersion *description.TopologyVersion, maxAwaitTimeMS int64) (description.Server, time.Duration, error) {
+	f.calls = append(f.calls, streaming)
+	i := len(f.calls) - 1
+	if i >= len(f.results) {
+		// Repeat the last scripted result so a long-running monitor loop
+		// doesn't run off the end of the script.
+		i = len(f.results) - 1
+	}
+	r := f.results[i]
+	return r.desc, r.rtt, r.err
+}
+
+func TestServerMonitor(t *testing.T) {
+	t.Run("engages streaming after a successful check on a streaming-capable server", func(t *testing.T) {
+		tv1 := &description.TopologyVersion{ProcessID: "a", Counter: 1}
+		conn := &fakeIsMasterer{
+			results: []fakeIsMasterResult{
+				{desc: description.Server{Kind: description.RSPrimary, WireVersion: description.NewVersionRange(0, 9), TopologyVersion: tv1}, rtt: 2 * time.Millisecond},
+			},
+		}
+		cfg := newServerConfig(WithServerMonitoringMode(true))
+		m := newServerMonitor(nil, conn, cfg, newRTTMonitor(conn))
+
+		desc := m.check(context.Background())
+		require.Equal(t, description.RSPrimary, desc.Kind)
+		require.False(t, conn.calls[0], "first check should never be streaming")
+		require.True(t, m.streaming, "should engage streaming once the server reports wire version >= 9")
+	})
+
+	t.Run("falls back to polling when the streaming socket errors", func(t *testing.T) {
+		tv1 := &description.TopologyVersion{ProcessID: "a", Counter: 1}
+		conn := &fakeIsMasterer{
+			results: []fakeIsMasterResult{
+				{desc: description.Server{Kind: description.RSPrimary, WireVersion: description.NewVersionRange(0, 9), TopologyVersion: tv1}, rtt: 2 * time.Millisecond},
+				{err: errors.New("socket closed")},
+			},
+		}
+		cfg := newServerConfig(WithServerMonitoringMode(true))
+		m := newServerMonitor(nil, conn, cfg, newRTTMonitor(conn))
+
+		m.check(context.Background())
+		require.True(t, m.streaming)
+		require.True(t, conn.calls[0] == false)
+
+		desc := m.check(context.Background())
+		require.Equal(t, description.Unknown, desc.Kind)
+		require.Error(t, desc.LastError)
+		require.False(t, m.streaming, "a streaming-socket error should fall back to polling")
+		require.Nil(t, m.topologyVersion)
+	})
+
+	t.Run("unchanged topology version is a no-op beyond reporting the current RTT", func(t *testing.T) {
+		tv1 := &description.TopologyVersion{ProcessID: "a", Counter: 1}
+		conn := &fakeIsMasterer{
+			results: []fakeIsMasterResult{
+				{desc: description.Server{Kind: description.RSPrimary, WireVersion: description.NewVersionRange(0, 9), TopologyVersion: tv1}},
+				{desc: description.Server{Kind: description.RSPrimary, WireVersion: description.NewVersionRange(0, 9), TopologyVersion: tv1}},
+			},
+		}
+		rtt := newRTTMonitor(conn)
+		rtt.sample(context.Background())
+		cfg := newServerConfig(WithServerMonitoringMode(true))
+		m := newServerMonitor(nil, conn, cfg, rtt)
+
+		m.check(context.Background())
+		desc := m.check(context.Background())
+
+		// calls[0] is the rtt monitor's own sample on the shared fake, so the
+		// monitor's second check is calls[2].
+		require.True(t, conn.calls[2], "second check should be streaming once engaged")
+		require.Equal(t, tv1, m.topologyVersion)
+		require.Equal(t, rtt.getRTT(), desc.AverageRTT)
+	})
+
+	t.Run("AverageRTT is not derived from the streaming isMaster's own blocking duration", func(t *testing.T) {
+		tv1 := &description.TopologyVersion{ProcessID: "a", Counter: 1}
+		// A real streaming check can legitimately block on the server for up
+		// to maxAwaitTimeMS; the scripted rtt here stands in for that, and
+		// must never reach AverageRTT.
+		conn := &fakeIsMasterer{
+			results: []fakeIsMasterResult{
+				{desc: description.Server{Kind: description.RSPrimary, WireVersion: description.NewVersionRange(0, 9), TopologyVersion: tv1}, rtt: 10 * time.Second},
+			},
+		}
+		rttConn := &fakeIsMasterer{
+			results: []fakeIsMasterResult{
+				{desc: description.Server{Kind: description.RSPrimary}, rtt: 2 * time.Millisecond},
+			},
+		}
+		rtt := newRTTMonitor(rttConn)
+		rtt.sample(context.Background())
+		cfg := newServerConfig(WithServerMonitoringMode(true))
+		m := newServerMonitor(nil, conn, cfg, rtt)
+
+		desc := m.check(context.Background())
+		require.Equal(t, 2*time.Millisecond, desc.AverageRTT)
+	})
+
+	t.Run("never streams against a server below the minimum wire version", func(t *testing.T) {
+		conn := &fakeIsMasterer{
+			results: []fakeIsMasterResult{
+				{desc: description.Server{Kind: description.RSPrimary, WireVersion: description.NewVersionRange(0, 6)}, rtt: time.Millisecond},
+				{desc: description.Server{Kind: description.RSPrimary, WireVersion: description.NewVersionRange(0, 6)}, rtt: time.Millisecond},
+			},
+		}
+		cfg := newServerConfig(WithServerMonitoringMode(true))
+		m := newServerMonitor(nil, conn, cfg, newRTTMonitor(conn))
+
+		m.check(context.Background())
+		m.check(context.Background())
+
+		require.False(t, m.streaming)
+		require.False(t, conn.calls[1])
+	})
+
+	t.Run("Connect starts the monitor and Disconnect stops it", func(t *testing.T) {
+		conn := &fakeIsMasterer{
+			results: []fakeIsMasterResult{
+				{desc: description.Server{Kind: description.RSPrimary, WireVersion: description.NewVersionRange(0, 6)}, rtt: time.Millisecond},
+			},
+		}
+
+		s, err := NewServer(address.Address("localhost"), nil, WithHeartbeatInterval(5*time.Millisecond))
+		require.NoError(t, err)
+		s.pool, err = NewTestPool(false, false, nil)
+		require.NoError(t, err)
+		s.newMonitorConn = func(address.Address) isMasterer { return conn }
+
+		require.NoError(t, s.Connect(context.Background()))
+
+		require.Eventually(t, func() bool {
+			return s.Description().Kind == description.RSPrimary
+		}, time.Second, time.Millisecond, "monitor goroutine should update the server's description")
+
+		require.NoError(t, s.Disconnect(context.Background()))
+		require.Equal(t, disconnected, atomic.LoadInt32(&s.connectionstate))
+
+		callsAtDisconnect := len(conn.calls)
+		time.Sleep(20 * time.Millisecond)
+		require.Equal(t, callsAtDisconnect, len(conn.calls), "monitor goroutine should have stopped checking after Disconnect")
+	})
+}
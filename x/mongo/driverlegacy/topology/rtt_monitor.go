@@ -0,0 +1,81 @@
+// Copyright (C) MongoDB, Inc. 2017-present.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at http://www.apache.org/licenses/LICENSE-2.0
+
+package topology
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// minRTTSampleInterval is how often the rttMonitor samples RTT, independent
+// of the main monitor's heartbeatInterval. It's deliberately short: once the
+// main monitor engages streaming, its isMaster calls can legitimately block
+// for up to heartbeatInterval waiting on the server, so RTT has to come from
+// somewhere that doesn't share that wait.
+const minRTTSampleInterval = 500 * time.Millisecond
+
+// rttMonitor tracks a server's round-trip time independently of the main
+// serverMonitor's isMaster checks, by sending its own small, never-streamed
+// isMaster on a fixed cadence over a dedicated connection. This keeps
+// AverageRTT a measure of network latency even once the main monitor's
+// checks are awaitable isMasters that can block on the server for seconds
+// at a time.
+type rttMonitor struct {
+	conn isMasterer
+
+	mu            sync.Mutex
+	averageRTTSet bool
+	averageRTT    time.Duration
+}
+
+func newRTTMonitor(conn isMasterer) *rttMonitor {
+	return &rttMonitor{conn: conn}
+}
+
+// sample sends a single non-awaitable isMaster and folds its RTT into the
+// moving average. A failed isMaster is ignored here; the main monitor's own
+// check against the same server is what surfaces connectivity errors.
+func (r *rttMonitor) sample(ctx context.Context) {
+	_, rtt, err := r.conn.isMaster(ctx, false, nil, 0)
+	if err != nil {
+		return
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if !r.averageRTTSet {
+		r.averageRTT = rtt
+		r.averageRTTSet = true
+		return
+	}
+	r.averageRTT = time.Duration(rttAlpha*float64(rtt) + (1-rttAlpha)*float64(r.averageRTT))
+}
+
+// getRTT returns the current exponentially-weighted moving average RTT.
+func (r *rttMonitor) getRTT() time.Duration {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.averageRTT
+}
+
+// run samples once immediately and then on every tick of minRTTSampleInterval
+// until ctx is cancelled.
+func (r *rttMonitor) run(ctx context.Context) {
+	r.sample(ctx)
+
+	ticker := time.NewTicker(minRTTSampleInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			r.sample(ctx)
+		case <-ctx.Done():
+			return
+		}
+	}
+}
@@ -0,0 +1,328 @@
+// Copyright (C) MongoDB, Inc. 2017-present.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at http://www.apache.org/licenses/LICENSE-2.0
+
+package topology
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"go.mongodb.org/mongo-driver/x/mongo/driverlegacy/command"
+	"go.mongodb.org/mongo-driver/x/network/address"
+	"go.mongodb.org/mongo-driver/x/network/connection"
+	"go.mongodb.org/mongo-driver/x/network/description"
+	"go.mongodb.org/mongo-driver/x/network/result"
+)
+
+// Server connection states.
+const (
+	disconnected int32 = iota
+	disconnecting
+	connected
+	connecting
+)
+
+// Server is a single server in a topology. It holds a pool of connections
+// and the most recently known description of the server it represents.
+type Server struct {
+	address address.Address
+
+	connectionstate int32
+
+	desc           atomic.Value // holds a description.Server
+	updateCallback func(description.Server)
+
+	pool connection.Pool
+	cfg  *serverConfig
+
+	monitor       *serverMonitor
+	rttMonitor    *rttMonitor
+	monitorCancel context.CancelFunc
+	monitorWG     sync.WaitGroup
+
+	// newMonitorConn builds the isMasterer the monitor goroutine checks
+	// against. It's a field rather than a direct call to newConnIsMasterer
+	// so tests can substitute a fake instead of dialing a real connection.
+	newMonitorConn func(address.Address) isMasterer
+}
+
+// NewServer creates a new Server for the given address. updateCallback, if
+// non-nil, is invoked every time the Server's description changes, except
+// when an initial description is set.
+func NewServer(addr address.Address, updateCallback func(description.Server), opts ...ServerOption) (*Server, error) {
+	s := &Server{
+		address:        addr,
+		updateCallback: updateCallback,
+		cfg:            newServerConfig(opts...),
+		newMonitorConn: func(addr address.Address) isMasterer { return newConnIsMasterer(addr) },
+	}
+	s.desc.Store(description.Server{Addr: addr})
+
+	pool, err := connection.NewPool(addr, nil)
+	if err != nil {
+		return nil, err
+	}
+	s.pool = pool
+
+	return s, nil
+}
+
+// Description returns the most recently known description of the server.
+func (s *Server) Description() description.Server {
+	return s.desc.Load().(description.Server)
+}
+
+// updateDescription replaces the server's current description and, unless
+// this is the server's initial description, invokes the update callback so
+// the owning topology can react to the change.
+func (s *Server) updateDescription(desc description.Server, initial bool) {
+	s.desc.Store(desc)
+	if !initial && s.updateCallback != nil {
+		s.updateCallback(desc)
+	}
+}
+
+// Connect starts the server: it connects the underlying pool and starts the
+// background monitor and rtt goroutines that keep the server's description
+// up to date. It does nothing if the server is not currently disconnected.
+func (s *Server) Connect(ctx context.Context) error {
+	if !atomic.CompareAndSwapInt32(&s.connectionstate, disconnected, connecting) {
+		return nil
+	}
+
+	if err := s.pool.Connect(ctx); err != nil {
+		return err
+	}
+	atomic.StoreInt32(&s.connectionstate, connected)
+
+	monitorCtx, cancel := context.WithCancel(context.Background())
+	s.monitorCancel = cancel
+	s.rttMonitor = newRTTMonitor(s.newMonitorConn(s.address))
+	s.monitor = newServerMonitor(s, s.newMonitorConn(s.address), s.cfg, s.rttMonitor)
+	s.monitorWG.Add(2)
+	go s.monitorLoop(monitorCtx)
+	go s.rttLoop(monitorCtx)
+
+	return nil
+}
+
+// Disconnect stops the background monitor and disconnects the underlying
+// pool.
+func (s *Server) Disconnect(ctx context.Context) error {
+	if !atomic.CompareAndSwapInt32(&s.connectionstate, connected, disconnecting) {
+		return nil
+	}
+
+	if s.monitorCancel != nil {
+		s.monitorCancel()
+		s.monitorWG.Wait()
+	}
+
+	err := s.pool.Disconnect(ctx)
+	atomic.StoreInt32(&s.connectionstate, disconnected)
+	return err
+}
+
+// monitorLoop repeatedly checks the server's isMaster until ctx is
+// cancelled (by Disconnect), sleeping heartbeatInterval between checks in
+// polling mode. Once the monitor has engaged streaming mode, each check
+// itself blocks on the server for up to heartbeatInterval (maxAwaitTimeMS),
+// so no additional sleep is needed between streaming checks.
+func (s *Server) monitorLoop(ctx context.Context) {
+	defer s.monitorWG.Done()
+
+	for {
+		desc := s.monitor.check(ctx)
+		s.updateDescription(desc, false)
+
+		if ctx.Err() != nil {
+			return
+		}
+
+		if s.monitor.streaming {
+			continue
+		}
+
+		select {
+		case <-time.After(s.cfg.heartbeatInterval):
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// rttLoop runs the server's rtt monitor until ctx is cancelled (by
+// Disconnect), sampling RTT on its own short cadence independent of
+// monitorLoop's heartbeatInterval.
+func (s *Server) rttLoop(ctx context.Context) {
+	defer s.monitorWG.Done()
+
+	s.rttMonitor.run(ctx)
+}
+
+// failPointPool is implemented by pools that can simulate a fail-point-
+// driven command error on their next use, as this package's test pool
+// does. No production pool implements it, so this is always a no-op
+// outside tests.
+type failPointPool interface {
+	nextCommandError(ctx context.Context) error
+}
+
+// Connection gets a connection to the server. If the pool fails to hand
+// back a connection, the server can no longer be trusted, so its
+// description is marked Unknown and the pool is drained. The returned
+// error wraps the underlying cause so callers can use errors.Is/errors.As
+// instead of matching on the error string.
+func (s *Server) Connection(ctx context.Context) (connection.Connection, error) {
+	conn, desc, err := s.pool.Get(ctx)
+	if err != nil {
+		if desc != nil {
+			desc.Kind = description.Unknown
+			desc.LastError = err
+			s.updateDescription(*desc, false)
+		}
+		_ = s.pool.Drain()
+		return nil, wrapConnectionError(err)
+	}
+
+	if fp, ok := s.pool.(failPointPool); ok {
+		if cmdErr := fp.nextCommandError(ctx); cmdErr != nil {
+			if errors.Is(cmdErr, context.DeadlineExceeded) || errors.Is(cmdErr, context.Canceled) {
+				return nil, cmdErr
+			}
+			s.ProcessError(cmdErr)
+			return nil, cmdErr
+		}
+	}
+
+	return conn, nil
+}
+
+// wrapConnectionError wraps a raw error from the pool in the topology error
+// type that best describes it, so callers can distinguish a checkout
+// timeout from any other connection failure.
+func wrapConnectionError(err error) error {
+	if errors.Is(err, context.DeadlineExceeded) {
+		return WaitQueueTimeoutError{Wrapped: err}
+	}
+	return ConnectionError{Wrapped: err}
+}
+
+// ProcessWriteConcernError handles a write concern error returned by an
+// operation against this server, classifying it per the SDAM "state
+// change" error-handling rules and updating the server accordingly.
+func (s *Server) ProcessWriteConcernError(wce *result.WriteConcernError) {
+	if wce == nil {
+		return
+	}
+	s.ProcessError(wce)
+}
+
+// ProcessError inspects err (a *command.Error or a *result.WriteConcernError)
+// and, per the SDAM "state change" error-handling rules, marks the server
+// Unknown and decides whether the connection pool needs to be drained.
+//
+// A "node is shutting down" error always drains the pool, since the server
+// is going away and none of its connections can be reused. A "not master"
+// or "node is recovering" error only drains the pool when the server's
+// most recently known wire version is below 8 or no wire version is known
+// yet; starting with wire version
+// 8, the server is simply marked Unknown, since the existing connections
+// are still usable and a fresh description will be picked up on the next
+// heartbeat.
+//
+// Errors that don't fall into one of these classes are ignored; they don't
+// indicate anything about the server's reachability.
+func (s *Server) ProcessError(err error) {
+	code, ok := errorCode(err)
+	if !ok {
+		return
+	}
+
+	class := classifyError(code)
+	if class == errClassNone {
+		return
+	}
+
+	desc := s.Description()
+	desc.Kind = description.Unknown
+	desc.LastError = err
+	s.updateDescription(desc, false)
+
+	if class == errClassShutdown || desc.WireVersion == nil || desc.WireVersion.Max < 8 {
+		_ = s.pool.Drain()
+	}
+}
+
+// errorCode extracts the server error code from a command error or write
+// concern error, the two error shapes the operation layer hands to
+// ProcessError.
+func errorCode(err error) (int32, bool) {
+	switch t := err.(type) {
+	case *command.Error:
+		return t.Code, true
+	case *result.WriteConcernError:
+		return int32(t.Code), true
+	default:
+		return 0, false
+	}
+}
+
+// errClass categorizes a server error per the SDAM "state change" rules.
+type errClass uint8
+
+const (
+	errClassNone errClass = iota
+	errClassShutdown
+	errClassNotMaster
+	errClassRecovering
+)
+
+// Error codes recognized by the SDAM state-change error-handling rules. See
+// the Server Discovery And Monitoring spec's "Error Handling" section.
+const (
+	codeInterruptedAtShutdown           int32 = 11600
+	codeShutdownInProgress              int32 = 91
+	codeNotMaster                       int32 = 10107
+	codeNotMasterNoSlaveOk              int32 = 13435
+	codeNotMasterLegacy                 int32 = 10058
+	codeInterruptedDueToReplStateChange int32 = 11602
+	codeNotMasterOrSecondary            int32 = 13436
+	codePrimarySteppedDown              int32 = 189
+	codeNotMasterOrSecondaryLegacy      int32 = 13388
+)
+
+// classifyError returns the SDAM error class for a server error code, or
+// errClassNone if the code isn't one that requires a state change.
+func classifyError(code int32) errClass {
+	switch code {
+	case codeInterruptedAtShutdown, codeShutdownInProgress:
+		return errClassShutdown
+	case codeNotMaster, codeNotMasterNoSlaveOk, codeNotMasterLegacy:
+		return errClassNotMaster
+	case codeInterruptedDueToReplStateChange, codeNotMasterOrSecondary, codePrimarySteppedDown, codeNotMasterOrSecondaryLegacy:
+		return errClassRecovering
+	default:
+		return errClassNone
+	}
+}
+
+// wceIsNotMasterOrRecovering reports whether wce indicates that the server
+// is no longer master or is currently in recovery.
+func wceIsNotMasterOrRecovering(wce *result.WriteConcernError) bool {
+	if wce == nil {
+		return false
+	}
+	switch classifyError(int32(wce.Code)) {
+	case errClassNotMaster, errClassRecovering, errClassShutdown:
+		return true
+	default:
+		return false
+	}
+}
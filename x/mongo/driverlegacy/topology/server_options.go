@@ -0,0 +1,56 @@
+// Copyright (C) MongoDB, Inc. 2017-present.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at http://www.apache.org/licenses/LICENSE-2.0
+
+package topology
+
+import "time"
+
+const defaultHeartbeatInterval = 10 * time.Second
+
+// serverConfig holds the configuration built up from a Server's
+// ServerOptions.
+type serverConfig struct {
+	heartbeatInterval time.Duration
+	streamingEnabled  bool
+}
+
+func newServerConfig(opts ...ServerOption) *serverConfig {
+	cfg := &serverConfig{
+		heartbeatInterval: defaultHeartbeatInterval,
+	}
+	for _, opt := range opts {
+		if opt == nil {
+			continue
+		}
+		opt(cfg)
+	}
+	return cfg
+}
+
+// ServerOption configures a Server.
+type ServerOption func(*serverConfig)
+
+// WithHeartbeatInterval configures the interval on which a Server checks
+// its isMaster in polling mode, and the maxAwaitTimeMS a streaming-mode
+// Server asks the server to block for between topology version changes.
+func WithHeartbeatInterval(interval time.Duration) ServerOption {
+	return func(cfg *serverConfig) {
+		cfg.heartbeatInterval = interval
+	}
+}
+
+// WithServerMonitoringMode opts the Server into the "awaitable isMaster"
+// streaming monitoring protocol: once the server's wire version supports
+// it, the monitor sends isMaster with maxAwaitTimeMS and a topologyVersion
+// on a dedicated socket and lets the server push a new description as soon
+// as it changes, instead of polling on a fixed interval. The monitor falls
+// back to polling mode on its own if streaming isn't supported or a
+// network error occurs on the streaming socket.
+func WithServerMonitoringMode(streamingEnabled bool) ServerOption {
+	return func(cfg *serverConfig) {
+		cfg.streamingEnabled = streamingEnabled
+	}
+}
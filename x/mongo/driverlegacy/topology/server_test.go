@@ -8,11 +8,15 @@ package topology
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"sync/atomic"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/require"
 	"go.mongodb.org/mongo-driver/x/mongo/driverlegacy/auth"
+	"go.mongodb.org/mongo-driver/x/mongo/driverlegacy/command"
 	"go.mongodb.org/mongo-driver/x/network/address"
 	connectionlegacy "go.mongodb.org/mongo-driver/x/network/connection"
 	"go.mongodb.org/mongo-driver/x/network/description"
@@ -24,9 +28,15 @@ type testpool struct {
 	drainCalled     atomic.Value
 	networkError    bool
 	desc            *description.Server
+	wireVersion     *description.VersionRange
+	ctxErr          error
+	failPoint       *failPoint
 }
 
 func (p *testpool) Get(ctx context.Context) (connectionlegacy.Connection, *description.Server, error) {
+	if p.ctxErr != nil {
+		return nil, p.desc, p.ctxErr
+	}
 	if p.connectionError {
 		return nil, p.desc, &auth.Error{}
 	}
@@ -50,10 +60,21 @@ func (p *testpool) Drain() error {
 }
 
 func NewTestPool(connectionError bool, networkError bool, desc *description.Server) (connectionlegacy.Pool, error) {
+	return NewTestPoolWithWireVersion(connectionError, networkError, desc, nil)
+}
+
+// NewTestPoolWithWireVersion is like NewTestPool but additionally stamps desc
+// (when non-nil) with wireVersion, so tests can exercise the wire-version
+// dependent SDAM error-handling paths.
+func NewTestPoolWithWireVersion(connectionError bool, networkError bool, desc *description.Server, wireVersion *description.VersionRange) (connectionlegacy.Pool, error) {
+	if desc != nil {
+		desc.WireVersion = wireVersion
+	}
 	p := &testpool{
 		connectionError: connectionError,
 		networkError:    networkError,
 		desc:            desc,
+		wireVersion:     wireVersion,
 	}
 	p.drainCalled.Store(false)
 	return p, nil
@@ -148,6 +169,229 @@ func TestServer(t *testing.T) {
 		drained := s.pool.(*testpool).drainCalled.Load().(bool)
 		require.Equal(t, drained, false)
 	})
+	t.Run("ProcessError", func(t *testing.T) {
+		var processErrorTestTable = []struct {
+			name        string
+			code        int32
+			wireVersion *description.VersionRange
+			wantDrained bool
+		}{
+			{"shutdown, old wire version", codeInterruptedAtShutdown, description.NewVersionRange(0, 6), true},
+			{"shutdown, new wire version", codeShutdownInProgress, description.NewVersionRange(0, 8), true},
+			{"not master, old wire version", codeNotMaster, description.NewVersionRange(0, 6), true},
+			{"not master, new wire version", codeNotMasterNoSlaveOk, description.NewVersionRange(0, 8), false},
+			{"recovering, old wire version", codePrimarySteppedDown, description.NewVersionRange(0, 7), true},
+			{"recovering, new wire version", codeInterruptedDueToReplStateChange, description.NewVersionRange(0, 9), false},
+			{"unclassified error is ignored", 99999, description.NewVersionRange(0, 8), false},
+		}
+
+		for _, tt := range processErrorTestTable {
+			t.Run(tt.name, func(t *testing.T) {
+				s, err := NewServer(address.Address("localhost"), nil)
+				require.NoError(t, err)
+
+				descript := s.Description()
+				descript.WireVersion = tt.wireVersion
+				s.updateDescription(descript, true)
+
+				desc := descript
+				s.pool, err = NewTestPoolWithWireVersion(false, false, &desc, tt.wireVersion)
+				s.connectionstate = connected
+
+				s.ProcessError(&command.Error{Code: tt.code})
+
+				if tt.code == 99999 {
+					require.Nil(t, s.Description().LastError)
+				} else {
+					require.Equal(t, s.Description().Kind, (description.ServerKind)(description.Unknown))
+					require.NotNil(t, s.Description().LastError)
+				}
+
+				drained := s.pool.(*testpool).drainCalled.Load().(bool)
+				require.Equal(t, drained, tt.wantDrained)
+			})
+		}
+	})
+	t.Run("fail point driven error handling", func(t *testing.T) {
+		var failPointTestTable = []struct {
+			name         string
+			mode         failPointMode
+			data         failPointData
+			wireVersion  *description.VersionRange
+			invocations  int
+			wantErrAt    map[int]bool // invocation index (0-based) -> whether Connection should error
+			wantDrainsAt map[int]bool // invocation index -> whether the pool should be drained after that call
+		}{
+			{
+				name:         "NotMaster twice then succeeds, old wire version drains each time",
+				mode:         failPointMode{Times: 2},
+				data:         failPointData{ErrorCode: codeNotMaster},
+				wireVersion:  description.NewVersionRange(0, 6),
+				invocations:  3,
+				wantErrAt:    map[int]bool{0: true, 1: true, 2: false},
+				wantDrainsAt: map[int]bool{0: true, 1: true, 2: true},
+			},
+			{
+				name:         "NotMaster twice then succeeds, new wire version never drains",
+				mode:         failPointMode{Times: 2},
+				data:         failPointData{ErrorCode: codeNotMasterNoSlaveOk},
+				wireVersion:  description.NewVersionRange(0, 8),
+				invocations:  3,
+				wantErrAt:    map[int]bool{0: true, 1: true, 2: false},
+				wantDrainsAt: map[int]bool{0: false, 1: false, 2: false},
+			},
+			{
+				name:         "skips the first invocation before firing",
+				mode:         failPointMode{Skip: 1, Times: 1},
+				data:         failPointData{ErrorCode: codeShutdownInProgress},
+				wireVersion:  description.NewVersionRange(0, 8),
+				invocations:  2,
+				wantErrAt:    map[int]bool{0: false, 1: true},
+				wantDrainsAt: map[int]bool{0: false, 1: true},
+			},
+			{
+				name:         "shutdown error always drains, even on a new wire version",
+				mode:         failPointMode{Times: 1},
+				data:         failPointData{ErrorCode: codeInterruptedAtShutdown},
+				wireVersion:  description.NewVersionRange(0, 9),
+				invocations:  1,
+				wantErrAt:    map[int]bool{0: true},
+				wantDrainsAt: map[int]bool{0: true},
+			},
+		}
+
+		for _, tt := range failPointTestTable {
+			t.Run(tt.name, func(t *testing.T) {
+				s, err := NewServer(address.Address("localhost"), nil)
+				require.NoError(t, err)
+
+				descript := s.Description()
+				descript.WireVersion = tt.wireVersion
+				s.updateDescription(descript, true)
+
+				desc := descript
+				pool, err := NewTestPoolWithWireVersion(false, false, &desc, tt.wireVersion)
+				require.NoError(t, err)
+				tp := pool.(*testpool)
+				tp.SetFailPoint("processErrorTest", tt.mode, tt.data)
+				s.pool = pool
+				s.connectionstate = connected
+
+				for i := 0; i < tt.invocations; i++ {
+					_, connErr := s.Connection(context.Background())
+					if tt.wantErrAt[i] {
+						require.Error(t, connErr)
+					} else {
+						require.NoError(t, connErr)
+					}
+
+					drained := tp.drainCalled.Load().(bool)
+					require.Equal(t, tt.wantDrainsAt[i], drained, "invocation %d", i)
+				}
+			})
+		}
+
+		t.Run("BlockConnection delays the response and respects context cancellation", func(t *testing.T) {
+			s, err := NewServer(address.Address("localhost"), nil)
+			require.NoError(t, err)
+			pool, err := NewTestPool(false, false, nil)
+			require.NoError(t, err)
+			tp := pool.(*testpool)
+			tp.SetFailPoint("blockTest", failPointMode{Times: 1}, failPointData{
+				ErrorCode:       codeNotMaster,
+				BlockConnection: true,
+				BlockTimeMS:     20,
+			})
+			s.pool = pool
+			s.connectionstate = connected
+
+			start := time.Now()
+			_, connErr := s.Connection(context.Background())
+			require.Error(t, connErr)
+			require.GreaterOrEqual(t, time.Since(start), 20*time.Millisecond)
+
+			ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond)
+			defer cancel()
+			tp.SetFailPoint("blockTest", failPointMode{Times: 1}, failPointData{
+				ErrorCode:       codeNotMaster,
+				BlockConnection: true,
+				BlockTimeMS:     50,
+			})
+			_, connErr = s.Connection(ctx)
+			require.True(t, errors.Is(connErr, context.DeadlineExceeded))
+		})
+	})
+	t.Run("Connection wraps errors", func(t *testing.T) {
+		t.Run("network error", func(t *testing.T) {
+			s, err := NewServer(address.Address("localhost"), nil)
+			require.NoError(t, err)
+			s.pool, err = NewTestPool(false, true, nil)
+			s.connectionstate = connected
+
+			_, err = s.Connection(context.Background())
+			require.Error(t, err)
+
+			var networkErr *connectionlegacy.NetworkError
+			require.True(t, errors.As(err, &networkErr))
+		})
+		t.Run("auth error", func(t *testing.T) {
+			s, err := NewServer(address.Address("localhost"), nil)
+			require.NoError(t, err)
+			s.pool, err = NewTestPool(true, false, nil)
+			s.connectionstate = connected
+
+			_, err = s.Connection(context.Background())
+			require.Error(t, err)
+
+			var authErr *auth.Error
+			require.True(t, errors.As(err, &authErr))
+		})
+		t.Run("cancelled context", func(t *testing.T) {
+			s, err := NewServer(address.Address("localhost"), nil)
+			require.NoError(t, err)
+			p, err := NewTestPool(false, false, nil)
+			require.NoError(t, err)
+			p.(*testpool).ctxErr = context.Canceled
+			s.pool = p
+			s.connectionstate = connected
+
+			_, err = s.Connection(context.Background())
+			require.Error(t, err)
+			require.True(t, errors.Is(err, context.Canceled))
+		})
+		t.Run("deadline exceeded surfaces as a wait queue timeout", func(t *testing.T) {
+			s, err := NewServer(address.Address("localhost"), nil)
+			require.NoError(t, err)
+			p, err := NewTestPool(false, false, nil)
+			require.NoError(t, err)
+			p.(*testpool).ctxErr = context.DeadlineExceeded
+			s.pool = p
+			s.connectionstate = connected
+
+			_, err = s.Connection(context.Background())
+			require.Error(t, err)
+			require.True(t, errors.Is(err, context.DeadlineExceeded))
+
+			var waitQueueErr WaitQueueTimeoutError
+			require.True(t, errors.As(err, &waitQueueErr))
+		})
+		t.Run("wrapped deadline exceeded still surfaces as a wait queue timeout", func(t *testing.T) {
+			s, err := NewServer(address.Address("localhost"), nil)
+			require.NoError(t, err)
+			p, err := NewTestPool(false, false, nil)
+			require.NoError(t, err)
+			p.(*testpool).ctxErr = fmt.Errorf("checkout failed: %w", context.DeadlineExceeded)
+			s.pool = p
+			s.connectionstate = connected
+
+			_, err = s.Connection(context.Background())
+			require.Error(t, err)
+			require.True(t, errors.Is(err, context.DeadlineExceeded))
+
+			var waitQueueErr WaitQueueTimeoutError
+			require.True(t, errors.As(err, &waitQueueErr))
+		})
+	})
 	t.Run("update topology", func(t *testing.T) {
 		var updated bool
 		s, err := NewServer(address.Address("localhost"), func(description.Server) { updated = true })